@@ -1,7 +1,12 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Develop struct {
-	Mode      string `yaml:"mode"`
+	Mode      Mode   `yaml:"mode"`
 	LogFolder string `yaml:"logFolder"`
 }
 
@@ -12,3 +17,39 @@ const (
 	QAMode      Mode = "qa"
 	ReleaseMode Mode = "release"
 )
+
+// modeAliases maps the spellings seen in deploy files to a canonical Mode.
+var modeAliases = map[string]Mode{
+	"dev":         DevelopMode,
+	"develop":     DevelopMode,
+	"development": DevelopMode,
+	"local":       DevelopMode,
+	"qa":          QAMode,
+	"test":        QAMode,
+	"testing":     QAMode,
+	"stage":       QAMode,
+	"staging":     QAMode,
+	"release":     ReleaseMode,
+	"prod":        ReleaseMode,
+	"production":  ReleaseMode,
+}
+
+// ParseMode normalizes s to one of the known modes. Common aliases such as
+// "prod" or "development" are accepted; anything else is an error.
+func ParseMode(s string) (Mode, error) {
+	if m, ok := modeAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return m, nil
+	}
+	return "", fmt.Errorf("config: unknown mode %q (want dev, qa or release)", s)
+}
+
+// UnmarshalText validates the mode while the config file is decoded, so a
+// typo fails the load instead of silently running with an unknown mode.
+func (m *Mode) UnmarshalText(text []byte) error {
+	parsed, err := ParseMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestParseModeAliases(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want Mode
+	}{
+		{"dev", DevelopMode},
+		{"Development", DevelopMode},
+		{"qa", QAMode},
+		{"staging", QAMode},
+		{"prod", ReleaseMode},
+		{" production ", ReleaseMode},
+		{"release", ReleaseMode},
+	} {
+		got, err := ParseMode(test.in)
+		if err != nil {
+			t.Errorf("ParseMode(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseMode(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseModeInvalid(t *testing.T) {
+	if _, err := ParseMode("prodution"); err == nil {
+		t.Fatal("ParseMode(\"prodution\"): unexpected success")
+	}
+}
+
+func TestDevelopUnmarshalMode(t *testing.T) {
+	var d Develop
+	if err := json.Unmarshal([]byte(`{"Mode":"prod"}`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Mode != ReleaseMode {
+		t.Fatalf("Mode = %q, want %q", d.Mode, ReleaseMode)
+	}
+	if err := json.Unmarshal([]byte(`{"Mode":"prd"}`), &d); err == nil {
+		t.Fatal("unmarshal of unknown mode: unexpected success")
+	}
+}
+
+// TestDevelopYamlMode covers the path config files are actually loaded
+// through, where Develop.Mode is keyed by its yaml tag.
+func TestDevelopYamlMode(t *testing.T) {
+	var d Develop
+	if err := yaml.Unmarshal([]byte("mode: prod\n"), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Mode != ReleaseMode {
+		t.Fatalf("Mode = %q, want %q", d.Mode, ReleaseMode)
+	}
+	if err := yaml.Unmarshal([]byte("mode: prd\n"), &d); err == nil {
+		t.Fatal("yaml unmarshal of unknown mode: unexpected success")
+	}
+}
@@ -0,0 +1,320 @@
+// Package table reads and writes the binary table format emitted by
+// gre/tools/excel2json -format=bin.
+//
+// A file is laid out as
+//
+//	"GWTB" version
+//	uvarint(columns) { uvarint(len) name kind }...
+//	uvarint(rows) { uvarint(len) value... }...
+//
+// Strings, verbatim numbers and JSON cells are uvarint length prefixed, ints
+// are varints, floats are 8 little-endian bytes and bools a single byte.
+// Every row is length prefixed so a reader can skip rows without decoding
+// them.
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const (
+	magic   = "GWTB"
+	version = 1
+)
+
+type Kind byte
+
+const (
+	KindString Kind = iota + 1
+	KindInt
+	KindFloat
+	KindBool
+	KindJson   // arrays and json cells, kept as raw json
+	KindNumber // numbers that fit neither int64 nor float64, kept verbatim
+)
+
+type Column struct {
+	Name string // dotted names such as reward.gold nest in Objects
+	Kind Kind
+}
+
+// Table is a decoded sheet. Each row holds one value per column: string,
+// int64, float64, bool, json.RawMessage or json.Number depending on the
+// column kind.
+type Table struct {
+	Columns []Column
+	Rows    [][]interface{}
+}
+
+var errTruncated = errors.New("table: truncated data")
+
+func Encode(w io.Writer, t *Table) error {
+	buf := bytes.NewBufferString(magic)
+	buf.WriteByte(version)
+
+	putUvarint(buf, uint64(len(t.Columns)))
+	for _, col := range t.Columns {
+		putUvarint(buf, uint64(len(col.Name)))
+		buf.WriteString(col.Name)
+		buf.WriteByte(byte(col.Kind))
+	}
+
+	putUvarint(buf, uint64(len(t.Rows)))
+	var row bytes.Buffer
+	for idx, values := range t.Rows {
+		if len(values) != len(t.Columns) {
+			return fmt.Errorf("table: row %d has %d values, want %d", idx, len(values), len(t.Columns))
+		}
+		row.Reset()
+		for k, col := range t.Columns {
+			if err := putValue(&row, col.Kind, values[k]); err != nil {
+				return fmt.Errorf("table: row %d column %q: %w", idx, col.Name, err)
+			}
+		}
+		putUvarint(buf, uint64(row.Len()))
+		buf.Write(row.Bytes())
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func putValue(buf *bytes.Buffer, kind Kind, value interface{}) error {
+	var ok bool
+	switch kind {
+	case KindString:
+		var s string
+		if s, ok = value.(string); ok {
+			putUvarint(buf, uint64(len(s)))
+			buf.WriteString(s)
+		}
+	case KindInt:
+		var i int64
+		if i, ok = value.(int64); ok {
+			var tmp [binary.MaxVarintLen64]byte
+			buf.Write(tmp[:binary.PutVarint(tmp[:], i)])
+		}
+	case KindFloat:
+		var f float64
+		if f, ok = value.(float64); ok {
+			var tmp [8]byte
+			binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(f))
+			buf.Write(tmp[:])
+		}
+	case KindBool:
+		var b bool
+		if b, ok = value.(bool); ok {
+			if b {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+		}
+	case KindJson:
+		var raw json.RawMessage
+		if raw, ok = value.(json.RawMessage); ok {
+			putUvarint(buf, uint64(len(raw)))
+			buf.Write(raw)
+		}
+	case KindNumber:
+		var n json.Number
+		if n, ok = value.(json.Number); ok {
+			putUvarint(buf, uint64(len(n)))
+			buf.WriteString(string(n))
+		}
+	default:
+		return fmt.Errorf("unknown kind %d", kind)
+	}
+	if !ok {
+		return fmt.Errorf("value %v (%T) does not match kind %d", value, value, kind)
+	}
+	return nil
+}
+
+func putUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+// Decode parses a table from data, e.g. a file read whole or mmapped.
+// String and json values are copied, so data may be released afterwards.
+func Decode(data []byte) (*Table, error) {
+	if len(data) < len(magic)+1 || string(data[:len(magic)]) != magic {
+		return nil, errors.New("table: not a table file")
+	}
+	if v := data[len(magic)]; v != version {
+		return nil, fmt.Errorf("table: unsupported version %d", v)
+	}
+	r := &reader{data: data[len(magic)+1:]}
+
+	colNum, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{Columns: make([]Column, colNum)}
+	for k := range t.Columns {
+		name, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		t.Columns[k] = Column{Name: string(name), Kind: Kind(kind)}
+	}
+
+	rowNum, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	t.Rows = make([][]interface{}, rowNum)
+	for idx := range t.Rows {
+		rowData, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		rr := &reader{data: rowData}
+		row := make([]interface{}, len(t.Columns))
+		for k, col := range t.Columns {
+			if row[k], err = rr.value(col.Kind); err != nil {
+				return nil, fmt.Errorf("table: row %d column %q: %w", idx, col.Name, err)
+			}
+		}
+		if len(rr.data) != 0 {
+			return nil, fmt.Errorf("table: row %d has %d trailing bytes", idx, len(rr.data))
+		}
+		t.Rows[idx] = row
+	}
+	return t, nil
+}
+
+// Load reads and decodes the table file at path.
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Objects returns the rows in the same shape as the json export: one map
+// per row, with dotted column names nested and json cells unmarshalled.
+func (t *Table) Objects() ([]map[string]interface{}, error) {
+	objects := make([]map[string]interface{}, 0, len(t.Rows))
+	for idx, row := range t.Rows {
+		obj := map[string]interface{}{}
+		for k, col := range t.Columns {
+			value := row[k]
+			if raw, ok := value.(json.RawMessage); ok {
+				var v interface{}
+				if err := json.Unmarshal(raw, &v); err != nil {
+					return nil, fmt.Errorf("table: row %d column %q: %w", idx, col.Name, err)
+				}
+				value = v
+			}
+			path := strings.Split(col.Name, ".")
+			node := obj
+			for _, key := range path[:len(path)-1] {
+				child, ok := node[key].(map[string]interface{})
+				if !ok {
+					child = map[string]interface{}{}
+					node[key] = child
+				}
+				node = child
+			}
+			node[path[len(path)-1]] = value
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+type reader struct {
+	data []byte
+}
+
+func (r *reader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+// count reads a length that must fit in the remaining data, which keeps a
+// corrupt header from triggering a huge allocation.
+func (r *reader) count() (int, error) {
+	v, err := r.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if v > uint64(len(r.data)) {
+		return 0, errTruncated
+	}
+	return int(v), nil
+}
+
+func (r *reader) bytes() ([]byte, error) {
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, nil
+}
+
+func (r *reader) byte() (byte, error) {
+	if len(r.data) == 0 {
+		return 0, errTruncated
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b, nil
+}
+
+func (r *reader) value(kind Kind) (interface{}, error) {
+	switch kind {
+	case KindString:
+		b, err := r.bytes()
+		return string(b), err
+	case KindInt:
+		v, n := binary.Varint(r.data)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		r.data = r.data[n:]
+		return v, nil
+	case KindFloat:
+		if len(r.data) < 8 {
+			return nil, errTruncated
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(r.data))
+		r.data = r.data[8:]
+		return f, nil
+	case KindBool:
+		b, err := r.byte()
+		return b != 0, err
+	case KindJson:
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(append([]byte(nil), b...)), nil
+	case KindNumber:
+		b, err := r.bytes()
+		return json.Number(b), err
+	default:
+		return nil, fmt.Errorf("unknown kind %d", kind)
+	}
+}
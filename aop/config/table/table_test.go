@@ -0,0 +1,141 @@
+package table
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func sampleTable() *Table {
+	return &Table{
+		Columns: []Column{
+			{Name: "id", Kind: KindInt},
+			{Name: "name", Kind: KindString},
+			{Name: "reward.rate", Kind: KindFloat},
+			{Name: "reward.items", Kind: KindJson},
+			{Name: "open", Kind: KindBool},
+			{Name: "big", Kind: KindNumber},
+		},
+		Rows: [][]interface{}{
+			{int64(1), "first", 0.5, json.RawMessage(`[1,2,3]`), true, json.Number("18446744073709551615")},
+			{int64(-2), "", 0.0, json.RawMessage(`[]`), false, json.Number("1.0")},
+		},
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	want := sampleTable()
+	var buf bytes.Buffer
+	if err := Encode(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Decode(Encode(t)) = %v, want %v", got, want)
+	}
+
+	objects, err := got.Objects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantObject := map[string]interface{}{
+		"id":     int64(1),
+		"name":   "first",
+		"reward": map[string]interface{}{"rate": 0.5, "items": []interface{}{1.0, 2.0, 3.0}},
+		"open":   true,
+		"big":    json.Number("18446744073709551615"),
+	}
+	if !reflect.DeepEqual(objects[0], wantObject) {
+		t.Fatalf("Objects()[0] = %v, want %v", objects[0], wantObject)
+	}
+}
+
+func TestEncodeKindMismatch(t *testing.T) {
+	tb := &Table{
+		Columns: []Column{{Name: "id", Kind: KindInt}},
+		Rows:    [][]interface{}{{"1"}},
+	}
+	if err := Encode(&bytes.Buffer{}, tb); err == nil {
+		t.Fatal("Encode with a string in an int column: unexpected success")
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTable()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	for n := 0; n < len(data); n++ {
+		if _, err := Decode(data[:n]); err == nil {
+			t.Fatalf("Decode of %d/%d bytes: unexpected success", n, len(data))
+		}
+	}
+}
+
+func largeTable(rows int) *Table {
+	t := &Table{Columns: sampleTable().Columns}
+	for i := 0; i < rows; i++ {
+		t.Rows = append(t.Rows, []interface{}{int64(i), "name", float64(i) / 3, json.RawMessage(`[1,2,3]`), i%2 == 0, json.Number("1.5")})
+	}
+	return t
+}
+
+// BenchmarkDecode measures Decode alone, which yields flat typed rows.
+func BenchmarkDecode(b *testing.B) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, largeTable(10000)); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(buf.Len()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(buf.Bytes()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecodeObjects decodes into the same nested maps as
+// BenchmarkJsonUnmarshal, so the two compare equal work.
+func BenchmarkDecodeObjects(b *testing.B) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, largeTable(10000)); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(buf.Len()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t, err := Decode(buf.Bytes())
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := t.Objects(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkJsonUnmarshal is the baseline: the same rows as a json export.
+func BenchmarkJsonUnmarshal(b *testing.B) {
+	objects, err := largeTable(10000).Objects()
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := json.Marshal(objects)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(data, &rows); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"go/token"
+	"net/rpc"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// BuiltinService is registered on every Server so callers can check
+// connectivity and discover the registered methods before dispatching.
+const BuiltinService = "_rpc"
+
+var (
+	methodsMu sync.Mutex
+	methods   = map[string]struct{}{}
+
+	onceBuiltin sync.Once
+)
+
+// Register publishes rcvr on the default rpc server like rpc.Register and
+// records its methods for _rpc.ListMethods.
+func Register(rcvr interface{}) error {
+	return RegisterName(reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name(), rcvr)
+}
+
+// RegisterName is like Register but uses name instead of the receiver's
+// concrete type name.
+func RegisterName(name string, rcvr interface{}) error {
+	if err := rpc.RegisterName(name, rcvr); err != nil {
+		return err
+	}
+	recordMethods(name, reflect.TypeOf(rcvr))
+	return nil
+}
+
+// recordMethods keeps the methods net/rpc will actually serve, using the
+// same rules as its suitableMethods: func(args T1, reply *T2) error with
+// T1 and T2 exported or builtin.
+func recordMethods(name string, typ reflect.Type) {
+	methodsMu.Lock()
+	defer methodsMu.Unlock()
+	for i := 0; i < typ.NumMethod(); i++ {
+		m := typ.Method(i)
+		mt := m.Type
+		if !m.IsExported() || mt.NumIn() != 3 || mt.NumOut() != 1 {
+			continue
+		}
+		if !isExportedOrBuiltinType(mt.In(1)) {
+			continue
+		}
+		if reply := mt.In(2); reply.Kind() != reflect.Ptr || !isExportedOrBuiltinType(reply) {
+			continue
+		}
+		if mt.Out(0) != errType {
+			continue
+		}
+		methods[name+"."+m.Name] = struct{}{}
+	}
+}
+
+var errType = reflect.TypeOf((*error)(nil)).Elem()
+
+func isExportedOrBuiltinType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return token.IsExported(t.Name()) || t.PkgPath() == ""
+}
+
+func registerBuiltin() {
+	onceBuiltin.Do(func() {
+		if err := RegisterName(BuiltinService, new(builtin)); err != nil {
+			panic(err)
+		}
+	})
+}
+
+type builtin struct{}
+
+// Ping replies "pong".
+func (builtin) Ping(_ string, reply *string) error {
+	*reply = "pong"
+	return nil
+}
+
+// ListMethods replies with every registered "Service.Method", sorted.
+func (builtin) ListMethods(_ string, reply *[]string) error {
+	methodsMu.Lock()
+	list := make([]string, 0, len(methods))
+	for m := range methods {
+		list = append(list, m)
+	}
+	methodsMu.Unlock()
+	sort.Strings(list)
+	*reply = list
+	return nil
+}
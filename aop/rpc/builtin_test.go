@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/phuhao00/spoor"
+	"greatestworks/aop/logger"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "rpc_test_log")
+	if err != nil {
+		panic(err)
+	}
+	logger.SetLogging(&logger.LoggingSetting{
+		Dir:   dir,
+		Level: int(spoor.DEBUG),
+	})
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type echoService struct{}
+
+var onceEcho sync.Once
+
+func (echoService) Echo(args string, reply *string) error {
+	*reply = args
+	return nil
+}
+
+func TestBuiltinPingAndListMethods(t *testing.T) {
+	// the default net/rpc server is process wide, so -count=N must not
+	// register twice
+	var err error
+	onceEcho.Do(func() { err = RegisterName("Echo", new(echoService)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{}
+	srv.Init("127.0.0.1:0")
+	if srv.ln == nil {
+		t.Fatal("server failed to listen")
+	}
+	go srv.Run()
+	defer srv.Close()
+
+	c := NewRpcClient(srv.ln.Addr().String())
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	got, err := c.ListMethods()
+	if err != nil {
+		t.Fatalf("ListMethods: %v", err)
+	}
+	want := []string{"Echo.Echo", "_rpc.ListMethods", "_rpc.Ping"}
+	for _, m := range want {
+		found := false
+		for _, g := range got {
+			if g == m {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("ListMethods() = %v, missing %q", got, m)
+		}
+	}
+}
+
+type hiddenArgs struct{}
+
+type mixedService struct{}
+
+func (mixedService) Served(args string, reply *string) error { return nil }
+
+func (mixedService) Hidden(args hiddenArgs, reply *string) error { return nil }
+
+func (mixedService) NoPtrReply(args string, reply string) error { return nil }
+
+func TestRecordMethodsMatchesNetRpc(t *testing.T) {
+	recordMethods("Mixed", reflect.TypeOf(new(mixedService)))
+	methodsMu.Lock()
+	defer methodsMu.Unlock()
+	if _, ok := methods["Mixed.Served"]; !ok {
+		t.Error("Mixed.Served not recorded")
+	}
+	for _, m := range []string{"Mixed.Hidden", "Mixed.NoPtrReply"} {
+		if _, ok := methods[m]; ok {
+			t.Errorf("%s recorded, but net/rpc would not serve it", m)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"greatestworks/aop/logger"
 	rpcLocal "greatestworks/aop/rpc"
 	"greatestworks/aop/rpc/example/processor"
-	"net/rpc"
 )
 
 func main() {
@@ -16,7 +15,7 @@ func main() {
 		Prefix:       "",
 		WriterOption: nil,
 	})
-	if err := rpc.Register(new(processor.MockProcessor)); err != nil {
+	if err := rpcLocal.Register(new(processor.MockProcessor)); err != nil {
 		fmt.Println("register failed")
 		return
 	}
@@ -34,3 +34,16 @@ func (c *Client) Call(method string, args interface{}, reply interface{}) error
 	rpcClient.Close()
 	return err
 }
+
+// Ping checks that the server is reachable and serving rpc.
+func (c *Client) Ping() error {
+	var reply string
+	return c.Call(BuiltinService+".Ping", "", &reply)
+}
+
+// ListMethods returns the "Service.Method" names registered on the server.
+func (c *Client) ListMethods() ([]string, error) {
+	var reply []string
+	err := c.Call(BuiltinService+".ListMethods", "", &reply)
+	return reply, err
+}
@@ -18,6 +18,7 @@ type Server struct {
 func (srv *Server) Init(addr string) {
 
 	srv.Addr = addr
+	registerBuiltin()
 
 	tcpAddr, err := net.ResolveTCPAddr("tcp4", srv.Addr)
 
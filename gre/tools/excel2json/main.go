@@ -1,29 +1,47 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/xuri/excelize/v2"
+	"greatestworks/aop/config/table"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 var (
 	excelPath string
 	jsonPath  string
+	format    string
 )
 
 func init() {
 	flag.StringVar(&excelPath, "excelPath", "../../excel", "please")
 	flag.StringVar(&jsonPath, "jsonPath", "../../../aop/json", "please")
+	flag.StringVar(&format, "format", "json", "json, or bin for the aop/config/table binary format")
 }
 
 func main() {
 	flag.Parse()
+	if format != "json" && format != "bin" {
+		fmt.Println("unknown format:", format)
+		os.Exit(2)
+	}
 	filelist := getFileList(excelPath)
 	fmt.Println(filelist)
+	failed := 0
 	for _, file := range filelist {
-		parseFile(file)
+		failed += parseFile(file)
+	}
+	if failed > 0 {
+		// let CI notice a broken sheet instead of shipping stale output
+		fmt.Printf("%d sheet(s) failed\n", failed)
+		os.Exit(1)
 	}
 }
 
@@ -32,7 +50,7 @@ func getFileList(path string) []string {
 	finfo, _ := os.ReadDir(path)
 	for _, info := range finfo {
 		if filepath.Ext(info.Name()) == ".xlsx" {
-			real_path := path + "/" + info.Name()
+			real_path := filepath.Join(path, info.Name())
 			if info.IsDir() {
 				//all_file = append(all_file, getFileList(real_path)...)
 			} else {
@@ -52,24 +70,29 @@ type meta struct {
 
 type rowdata []interface{}
 
-func parseFile(file string) {
+// parseFile converts every sheet of file and returns how many failed.
+func parseFile(file string) int {
 
 	fmt.Println("\n\n\n\n", file)
 
 	xlsx, err := excelize.OpenFile(file)
 	if err != nil {
-		panic(err.Error())
+		fmt.Println(file, err)
+		return 1
 	}
+	failed := 0
 	//[line][colidx][data]
 
 	sheets := xlsx.GetSheetList()
 	for _, s := range sheets {
 		rows, err := xlsx.GetRows(s)
 		if err != nil {
-			return
+			fmt.Println(s, err)
+			failed++
+			continue
 		}
 		if len(rows) < 5 {
-			return
+			continue
 		}
 
 		colNum := len(rows[1])
@@ -90,7 +113,7 @@ func parseFile(file string) {
 				}
 			case 2: // data type
 
-				fmt.Println("meta cot:%d, rol cot:%d", len(metaList), len(row))
+				fmt.Printf("meta cot:%d, rol cot:%d\n", len(metaList), len(row))
 				for idx, typ := range row {
 					metaList[idx].Typ = typ
 				}
@@ -113,61 +136,359 @@ func parseFile(file string) {
 		// to json, save
 		//filename := filepath.Base(file)
 		//suf := filepath.Ext(filename)
+		if format == "bin" {
+			data, err := toBinary(dataList, metaList)
+			if err != nil {
+				fmt.Println(sheetName, err)
+				failed++
+				continue
+			}
+			err = writeFile(fmt.Sprintf("%s.bin", sheetName), data)
+			if err != nil {
+				fmt.Println(sheetName, err)
+				failed++
+			}
+			continue
+		}
 		jsonFile := fmt.Sprintf("%s.json", sheetName)
-		err = output(jsonFile, toJson(dataList, metaList))
+		str, err := toJson(dataList, metaList)
+		if err != nil {
+			fmt.Println(sheetName, err)
+			failed++
+			continue
+		}
+		err = output(jsonFile, str)
 		if err != nil {
-			fmt.Println(err)
+			fmt.Println(sheetName, err)
+			failed++
 		}
 		//fmt.Println(toJson(dataList, metaList))
 
 	}
 
+	return failed
 }
 
-func toJson(datarows []rowdata, metalist []*meta) string {
-	ret := "["
+// field is one key of the emitted object. Columns named with dots, such as
+// reward.gold and reward.exp, are grouped under a nested "reward" field.
+type field struct {
+	Key      string
+	Meta     *meta    // set for leaf columns
+	Children []*field // set for nested groups
+}
 
-	for _, row := range datarows {
-		ret += "\n\t{"
-		for idx, meta := range metalist {
-			ret += fmt.Sprintf("\n\t\t\"%s\":", meta.Key)
-			if meta.Typ == "string" {
-				if row[idx] == nil {
-					ret += "\"\""
-				} else {
-					ret += fmt.Sprintf("\"%s\"", row[idx])
-				}
-			} else {
-				if row[idx] == nil || row[idx] == "" {
-					ret += "0"
-				} else {
-					ret += fmt.Sprintf("%s", row[idx])
+func buildFields(metalist []*meta) ([]*field, error) {
+	root := &field{}
+	for _, m := range metalist {
+		if m.Key == "" { // unnamed column, e.g. a designer's note
+			continue
+		}
+		path := []string{m.Key}
+		if strings.Contains(m.Key, ".") {
+			path = strings.Split(m.Key, ".")
+		}
+		node := root
+		for depth, key := range path {
+			if key == "" {
+				return nil, fmt.Errorf("column %d: empty segment in name %q", m.Idx, m.Key)
+			}
+			var child *field
+			for _, c := range node.Children {
+				if c.Key == key {
+					child = c
+					break
 				}
 			}
-			ret += ","
+			leaf := depth == len(path)-1
+			if child == nil {
+				child = &field{Key: key}
+				node.Children = append(node.Children, child)
+			} else if leaf || child.Meta != nil {
+				return nil, fmt.Errorf("column %d: name %q conflicts with another column", m.Idx, m.Key)
+			}
+			if leaf {
+				child.Meta = m
+			}
+			node = child
 		}
-		ret = ret[:len(ret)-1]
+	}
+	return root.Children, nil
+}
 
-		ret += "\n\t},"
+func toJson(datarows []rowdata, metalist []*meta) (string, error) {
+	fields, err := buildFields(metalist)
+	if err != nil {
+		return "", err
 	}
-	ret = ret[:len(ret)-1]
 
+	ret := "["
+	for idx, row := range datarows {
+		obj, err := toJsonObject(fields, row, 2)
+		if err != nil {
+			// data starts at the 5th excel row
+			return "", fmt.Errorf("row %d: %w", idx+5, err)
+		}
+		if idx > 0 {
+			ret += ","
+		}
+		ret += "\n\t" + obj
+	}
 	ret += "\n]"
-	return ret
+	return ret, nil
+}
+
+func toJsonObject(fields []*field, row rowdata, depth int) (string, error) {
+	indent := strings.Repeat("\t", depth)
+	ret := "{"
+	for idx, f := range fields {
+		if idx > 0 {
+			ret += ","
+		}
+		key, _ := json.Marshal(f.Key)
+		ret += fmt.Sprintf("\n%s%s:", indent, key)
+		if f.Meta == nil {
+			obj, err := toJsonObject(f.Children, row, depth+1)
+			if err != nil {
+				return "", err
+			}
+			ret += obj
+			continue
+		}
+		cell, _ := row[f.Meta.Idx].(string)
+		value, err := toJsonValue(f.Meta.Typ, cell)
+		if err != nil {
+			return "", fmt.Errorf("column %q: %w", f.Meta.Key, err)
+		}
+		ret += value
+	}
+	ret += "\n" + strings.Repeat("\t", depth-1) + "}"
+	return ret, nil
+}
+
+// toJsonValue renders one cell according to its declared type. Any type
+// not listed below is treated as a number.
+func toJsonValue(typ string, cell string) (string, error) {
+	if typ == "string" {
+		b, err := json.Marshal(cell)
+		return string(b), err
+	}
+
+	cell = strings.TrimSpace(cell)
+	switch typ {
+	case "bool":
+		if cell == "" {
+			return "false", nil
+		}
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return "", fmt.Errorf("invalid bool %q", cell)
+		}
+		return strconv.FormatBool(b), nil
+	case "float":
+		if cell == "" {
+			return "0", nil
+		}
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("invalid float %q", cell)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case "array", "stringarray":
+		// 1;2;3 or 1,2,3 -> [1,2,3]; stringarray quotes each element instead
+		if cell == "" {
+			return "[]", nil
+		}
+		elems := strings.FieldsFunc(cell, func(r rune) bool { return r == ';' || r == ',' })
+		if len(elems) != strings.Count(cell, ";")+strings.Count(cell, ",")+1 {
+			return "", fmt.Errorf("empty element in %s %q", typ, cell)
+		}
+		ret := "["
+		for idx, elem := range elems {
+			if idx > 0 {
+				ret += ","
+			}
+			elem = strings.TrimSpace(elem)
+			switch {
+			case elem == "":
+				return "", fmt.Errorf("empty element in %s %q", typ, cell)
+			case typ == "stringarray":
+				b, _ := json.Marshal(elem)
+				ret += string(b)
+			case isJsonNumber(elem):
+				ret += elem
+			default:
+				return "", fmt.Errorf("invalid number %q in array %q", elem, cell)
+			}
+		}
+		return ret + "]", nil
+	case "json":
+		if cell == "" {
+			return "null", nil
+		}
+		if !json.Valid([]byte(cell)) {
+			return "", fmt.Errorf("invalid json %q", cell)
+		}
+		return cell, nil
+	default:
+		if cell == "" {
+			return "0", nil
+		}
+		if !isJsonNumber(cell) {
+			return "", fmt.Errorf("invalid %s %q", typ, cell)
+		}
+		return cell, nil
+	}
+}
+
+func isJsonNumber(s string) bool {
+	if s == "" || !(s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
+
+// toBinary encodes the sheet in the aop/config/table format. Cells go
+// through the same checks as toJson, so both exports hold the same rows.
+func toBinary(datarows []rowdata, metalist []*meta) ([]byte, error) {
+	if _, err := buildFields(metalist); err != nil {
+		return nil, err
+	}
+
+	metas := make([]*meta, 0, len(metalist))
+	for _, m := range metalist {
+		if m.Key != "" {
+			metas = append(metas, m)
+		}
+	}
+
+	// render every cell as toJson would, then pick per column the most
+	// compact kind that holds all of its values exactly
+	values := make([][]string, len(datarows))
+	for idx, row := range datarows {
+		values[idx] = make([]string, len(metas))
+		for k, m := range metas {
+			cell, _ := row[m.Idx].(string)
+			value, err := toJsonValue(m.Typ, cell)
+			if err != nil {
+				// data starts at the 5th excel row
+				return nil, fmt.Errorf("row %d: column %q: %w", idx+5, m.Key, err)
+			}
+			if m.Typ == "string" {
+				value = cell
+			}
+			values[idx][k] = value
+		}
+	}
+
+	t := &table.Table{Rows: make([][]interface{}, len(datarows))}
+	for k, m := range metas {
+		kind := tableKind(m.Typ)
+		for idx := range values {
+			if _, err := tableValue(kind, values[idx][k]); err != nil {
+				kind = table.KindNumber
+				break
+			}
+		}
+		t.Columns = append(t.Columns, table.Column{Name: m.Key, Kind: kind})
+	}
+	for idx := range values {
+		t.Rows[idx] = make([]interface{}, len(metas))
+		for k, col := range t.Columns {
+			t.Rows[idx][k], _ = tableValue(col.Kind, values[idx][k])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := table.Encode(&buf, t); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tableKind is the preferred kind for a declared type. Integer and float
+// columns fall back to table.KindNumber when a value does not fit, as toJson
+// accepts any json number for them; other types are kept verbatim as numbers.
+func tableKind(typ string) table.Kind {
+	switch typ {
+	case "string":
+		return table.KindString
+	case "bool":
+		return table.KindBool
+	case "float", "float32", "float64", "double":
+		return table.KindFloat
+	case "array", "stringarray", "json":
+		return table.KindJson
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return table.KindInt
+	default:
+		return table.KindNumber
+	}
+}
+
+// tableValue converts a cell already rendered by toJsonValue.
+func tableValue(kind table.Kind, value string) (interface{}, error) {
+	switch kind {
+	case table.KindString:
+		return value, nil
+	case table.KindBool:
+		return strconv.ParseBool(value)
+	case table.KindFloat:
+		// same rounding as encoding/json; fails only when out of range
+		return strconv.ParseFloat(value, 64)
+	case table.KindJson:
+		return json.RawMessage(value), nil
+	case table.KindInt:
+		return strconv.ParseInt(value, 10, 64)
+	default:
+		return json.Number(value), nil
+	}
 }
 
 func output(filename string, str string) error {
+	if err := validateJson(str); err != nil {
+		return err
+	}
+	return writeFile(filename, []byte(str))
+}
 
-	f, err := os.OpenFile(jsonPath+"\\"+filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+// writeFile writes next to the target and renames over it, so a failed run
+// never leaves a truncated file behind.
+func writeFile(filename string, data []byte) error {
+	path := filepath.Join(jsonPath, filename)
+	f, err := os.CreateTemp(jsonPath, filename+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer os.Remove(f.Name())
 
-	_, err = f.WriteString(str)
-	if err != nil {
+	if _, err = f.Write(data); err != nil {
+		f.Close()
 		return err
 	}
+	if err = f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
 
-	return nil
+// validateJson checks the assembled output and, when it is broken, reports
+// the excel row the bad object came from.
+func validateJson(str string) error {
+	var rows []json.RawMessage
+	err := json.Unmarshal([]byte(str), &rows)
+	if err == nil {
+		return nil
+	}
+	if syntaxErr, ok := err.(*json.SyntaxError); ok && syntaxErr.Offset <= int64(len(str)) {
+		row := strings.Count(str[:syntaxErr.Offset], "\n\t{")
+		if row > 0 {
+			// data starts at the 5th excel row
+			return fmt.Errorf("row %d: invalid json output: %w", row+4, err)
+		}
+	}
+	return fmt.Errorf("invalid json output: %w", err)
 }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"greatestworks/aop/config/table"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToJsonNestedColumns(t *testing.T) {
+	metas := []*meta{
+		{Key: "id", Idx: 0, Typ: "int"},
+		{Key: "reward.gold", Idx: 1, Typ: "int"},
+		{Key: "reward.exp", Idx: 2, Typ: "int"},
+		{Key: "reward.item.id", Idx: 3, Typ: "int"},
+		{Key: "name", Idx: 4, Typ: "string"},
+	}
+	rows := []rowdata{
+		{"1", "100", "20", "3001", "first"},
+		{"2", "", nil, nil, nil},
+	}
+	str, err := toJson(rows, metas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal([]byte(str), &got); err != nil {
+		t.Fatalf("invalid json %v:\n%s", err, str)
+	}
+	want := []map[string]interface{}{
+		{"id": 1.0, "reward": map[string]interface{}{"gold": 100.0, "exp": 20.0, "item": map[string]interface{}{"id": 3001.0}}, "name": "first"},
+		{"id": 2.0, "reward": map[string]interface{}{"gold": 0.0, "exp": 0.0, "item": map[string]interface{}{"id": 0.0}}, "name": ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("toJson() = %v, want %v", got, want)
+	}
+}
+
+func TestToJsonConflictingColumns(t *testing.T) {
+	metas := []*meta{
+		{Key: "reward", Idx: 0, Typ: "int"},
+		{Key: "reward.gold", Idx: 1, Typ: "int"},
+	}
+	if _, err := toJson([]rowdata{{"1", "2"}}, metas); err == nil {
+		t.Fatal("toJson with conflicting columns: unexpected success")
+	}
+}
+
+func TestToJsonTypedColumns(t *testing.T) {
+	metas := []*meta{
+		{Key: "items", Idx: 0, Typ: "array"},
+		{Key: "open", Idx: 1, Typ: "bool"},
+		{Key: "rate", Idx: 2, Typ: "float"},
+		{Key: "extra", Idx: 3, Typ: "json"},
+		{Key: "desc", Idx: 4, Typ: "string"},
+		{Key: "tags", Idx: 5, Typ: "stringarray"},
+	}
+	rows := []rowdata{
+		{"1;2;3", "true", "0.25", `{"a":[1,2]}`, `say "hi"`, "a; b,2"},
+		{"4,5", "0", nil, nil, nil, nil},
+	}
+	str, err := toJson(rows, metas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal([]byte(str), &got); err != nil {
+		t.Fatalf("invalid json %v:\n%s", err, str)
+	}
+	want := []map[string]interface{}{
+		{"items": []interface{}{1.0, 2.0, 3.0}, "open": true, "rate": 0.25, "extra": map[string]interface{}{"a": []interface{}{1.0, 2.0}}, "desc": `say "hi"`, "tags": []interface{}{"a", "b", "2"}},
+		{"items": []interface{}{4.0, 5.0}, "open": false, "rate": 0.0, "extra": nil, "desc": "", "tags": []interface{}{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("toJson() = %v, want %v", got, want)
+	}
+}
+
+func TestToJsonInvalidCell(t *testing.T) {
+	for _, test := range []struct {
+		typ  string
+		cell string
+	}{
+		{"bool", "yes"},
+		{"float", "1.2.3"},
+		{"json", "{broken"},
+		{"int", "12abc"},
+		{"array", "4,five"},
+		{"array", "1;2a;3"},
+		{"array", "1; ;2"},
+		{"array", "1;;2"},
+		{"array", "1;2;"},
+		{"stringarray", "a;;b"},
+	} {
+		metas := []*meta{{Key: "v", Idx: 0, Typ: test.typ}}
+		if _, err := toJson([]rowdata{{test.cell}}, metas); err == nil {
+			t.Errorf("toJson(%s %q): unexpected success", test.typ, test.cell)
+		}
+	}
+}
+
+func TestOutputKeepsPreviousFileOnInvalidJson(t *testing.T) {
+	jsonPath = t.TempDir()
+	if err := output("sheet.json", `[{"id":1}]`); err != nil {
+		t.Fatal(err)
+	}
+	if err := output("sheet.json", "[\n\t{\"id\":1},\n\t{\"id\":}\n]"); err == nil {
+		t.Fatal("output of invalid json: unexpected success")
+	} else if !strings.Contains(err.Error(), "row 6") {
+		t.Errorf("error %q does not name row 6", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(jsonPath, "sheet.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `[{"id":1}]` {
+		t.Fatalf("sheet.json = %s, want previous content", got)
+	}
+	entries, err := os.ReadDir(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("jsonPath has %d entries, want only sheet.json", len(entries))
+	}
+}
+
+func TestBinaryMatchesJson(t *testing.T) {
+	metas := []*meta{
+		{Key: "id", Idx: 0, Typ: "int"},
+		{Key: "name", Idx: 1, Typ: "string"},
+		{Key: "reward.gold", Idx: 2, Typ: "int"},
+		{Key: "reward.items", Idx: 3, Typ: "array"},
+		{Key: "rate", Idx: 4, Typ: "float"},
+		{Key: "open", Idx: 5, Typ: "bool"},
+		{Key: "extra", Idx: 6, Typ: "json"},
+	}
+	rows := []rowdata{
+		{"1", "first", "100", "1;2;3", "0.25", "true", `{"a":1}`},
+		{"2", nil, nil, nil, nil, nil, nil},
+	}
+
+	str, err := toJson(rows, metas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJson []map[string]interface{}
+	if err := json.Unmarshal([]byte(str), &fromJson); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := toBinary(rows, metas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb, err := table.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary, err := tb.Objects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSameRows(t, fromBinary, fromJson)
+}
+
+// assertSameRows compares rows the way a json consumer sees them, so int64,
+// float64 and json.Number values line up.
+func assertSameRows(t *testing.T, fromBinary, fromJson []map[string]interface{}) {
+	t.Helper()
+	data, err := json.Marshal(fromBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, fromJson) {
+		t.Fatalf("binary rows = %v\njson rows = %v", got, fromJson)
+	}
+}
+
+func TestBinaryNumericColumns(t *testing.T) {
+	for _, test := range []struct {
+		typ   string
+		cells []string
+		kind  table.Kind
+	}{
+		{"int", []string{"1", "-2"}, table.KindInt},
+		{"int", []string{"1", "1.0"}, table.KindNumber},
+		{"int", []string{"1e3"}, table.KindNumber},
+		{"int64", []string{"9223372036854775807"}, table.KindInt},
+		{"uint64", []string{"18446744073709551615"}, table.KindNumber},
+		{"float32", []string{"1.5", "2"}, table.KindFloat},
+		{"double", []string{"0.1", "-3e-2"}, table.KindFloat},
+		{"short", []string{"7"}, table.KindNumber},
+	} {
+		metas := []*meta{{Key: "v", Idx: 0, Typ: test.typ}}
+		rows := make([]rowdata, len(test.cells))
+		for i, cell := range test.cells {
+			rows[i] = rowdata{cell}
+		}
+
+		str, err := toJson(rows, metas)
+		if err != nil {
+			t.Fatalf("%s %v: toJson: %v", test.typ, test.cells, err)
+		}
+		var fromJson []map[string]interface{}
+		if err := json.Unmarshal([]byte(str), &fromJson); err != nil {
+			t.Fatal(err)
+		}
+		data, err := toBinary(rows, metas)
+		if err != nil {
+			t.Fatalf("%s %v: toBinary: %v", test.typ, test.cells, err)
+		}
+		tb, err := table.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tb.Columns[0].Kind; got != test.kind {
+			t.Errorf("%s %v: kind = %d, want %d", test.typ, test.cells, got, test.kind)
+		}
+		fromBinary, err := tb.Objects()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameRows(t, fromBinary, fromJson)
+	}
+}
+
+func TestBinaryKeepsLargeNumbersExact(t *testing.T) {
+	metas := []*meta{{Key: "v", Idx: 0, Typ: "uint64"}}
+	data, err := toBinary([]rowdata{{"18446744073709551615"}}, metas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb, err := table.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tb.Rows[0][0]; got != json.Number("18446744073709551615") {
+		t.Fatalf("value = %v (%T), want exact json.Number", got, got)
+	}
+}
+
+func TestToJsonEscapesKeys(t *testing.T) {
+	metas := []*meta{
+		{Key: `say "hi"`, Idx: 0, Typ: "int"},
+		{Key: `a\b.c`, Idx: 1, Typ: "int"},
+	}
+	str, err := toJson([]rowdata{{"1", "2"}}, metas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal([]byte(str), &got); err != nil {
+		t.Fatalf("invalid json %v:\n%s", err, str)
+	}
+	want := []map[string]interface{}{
+		{`say "hi"`: 1.0, `a\b`: map[string]interface{}{"c": 2.0}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("toJson() = %v, want %v", got, want)
+	}
+}